@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// probeSample is the outcome of a single connect-mode probe attempt,
+// classified into the error buckets the -o json/prom/influx formats
+// report. Refused and reset both still carry a Duration: a RST is a
+// response, just like SYN-ACK/RST in -M syn.
+type probeSample struct {
+	Duration time.Duration
+	HasRTT   bool
+	Error    string // "", "timeout", "refused", "reset", "dns", or "unreachable"
+	Tag      string // optional annotation: the resolved addr used, or SYN-ACK/RST
+}
+
+// classifyProbe dials addr once, bounding the attempt with a per-attempt
+// context deadline (rather than relying on net.Dialer.Timeout alone, so
+// an -i interval shorter than the timeout still can't let one slow
+// attempt bleed into the next), and sorts the result into an error
+// class, so callers can tell a closed port (refused) apart from a
+// filtered one (timeout) or a routing failure (unreachable).
+func classifyProbe(dialer net.Dialer, network, addr string, timeout time.Duration) probeSample {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, network, addr)
+	dur := time.Since(start)
+	if conn != nil {
+		_ = conn.Close()
+	}
+
+	if err == nil {
+		return probeSample{Duration: dur, HasRTT: true}
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return probeSample{Error: "dns"}
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return probeSample{Error: "timeout"}
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return probeSample{Duration: dur, HasRTT: true, Error: "refused"}
+	}
+	if errors.Is(err, syscall.ECONNRESET) {
+		return probeSample{Duration: dur, HasRTT: true, Error: "reset"}
+	}
+	if errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH) {
+		return probeSample{Error: "unreachable"}
+	}
+	return probeSample{Error: "unreachable"}
+}
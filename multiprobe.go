@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostSpec is one "host[:port]" argument, before DNS resolution.
+type hostSpec struct {
+	Host string
+	Port int
+}
+
+// parseHostSpec splits a "host[:port]" argument, defaulting to
+// defaultPort when no port is present. IPv6 literals must be bracketed
+// ("[::1]:80"), same as net.SplitHostPort.
+func parseHostSpec(s string, defaultPort int) (hostSpec, error) {
+	h, p, err := net.SplitHostPort(s)
+	if err != nil {
+		// No ":port" suffix (or a bare, unbracketed IPv6 literal): take
+		// the whole thing as the host and fall back to defaultPort.
+		return hostSpec{Host: s, Port: defaultPort}, nil
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil || port < 0 || port > 65535 {
+		return hostSpec{}, fmt.Errorf("invalid port in %q", s)
+	}
+	return hostSpec{Host: h, Port: port}, nil
+}
+
+// loadHostFile reads one "host[:port]" spec per line from path, skipping
+// blank lines and "#" comments, for the -f flag.
+func loadHostFile(path string, defaultPort int) ([]hostSpec, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open -f file: %w", err)
+	}
+	defer f.Close()
+
+	var specs []hostSpec
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		spec, err := parseHostSpec(line, defaultPort)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		specs = append(specs, spec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read -f file: %w", err)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("%s: no hosts listed", path)
+	}
+	return specs, nil
+}
+
+// probeOpts bundles the probe settings shared across all hosts in a run.
+type probeOpts struct {
+	Count     int
+	Timeout   time.Duration
+	Method    string
+	Family    int
+	LocalAddr net.Addr
+	Format    string // "fping", "json", "prom", or "influx"
+
+	// Interval paces repeated attempts against the same host so they
+	// don't dial back-to-back (0 = legacy back-to-back behavior).
+	Interval time.Duration
+	// Jitter randomizes Interval by up to this fraction (0-1).
+	Jitter float64
+	// WarmupTimeout, if >0, sends one discarded probe with this timeout
+	// before the counted attempts, to absorb cold cache/ARP latency.
+	WarmupTimeout time.Duration
+}
+
+// probeHost resolves and probes one host, returning its hostReport and
+// whether at least one sample produced an RTT. A missing CAP_NET_RAW for
+// -M syn is fatal for the whole run, since it is a process-wide condition.
+func probeHost(spec hostSpec, opts probeOpts) (hostReport, bool) {
+	report := hostReport{Host: spec.Host, Port: spec.Port}
+
+	addrs, err := resolveAddrs(spec.Host, opts.Family)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		report.Samples = []probeSample{{Error: "dns"}}
+		return report, false
+	}
+	showAddr := len(addrs) > 1
+	if !showAddr {
+		report.Addr = addrs[0]
+	}
+
+	network := dialNetwork(opts.Family)
+	dialer := net.Dialer{LocalAddr: opts.LocalAddr}
+
+	if opts.WarmupTimeout > 0 {
+		doAttempt(spec, addrs, 0, network, dialer, opts.Method, opts.WarmupTimeout)
+	}
+
+	report.Samples = make([]probeSample, 0, opts.Count)
+	for i := 0; i < opts.Count; i++ {
+		if i > 0 {
+			sleepInterval(opts.Interval, opts.Jitter)
+		}
+
+		s := doAttempt(spec, addrs, i, network, dialer, opts.Method, opts.Timeout)
+		if showAddr && opts.Method != "syn" {
+			s.Tag = addrs[i%len(addrs)]
+		}
+		report.Samples = append(report.Samples, s)
+	}
+
+	for _, s := range report.Samples {
+		if s.HasRTT {
+			return report, true
+		}
+	}
+	return report, false
+}
+
+// doAttempt runs one attempt against spec using either connect or syn
+// mode, bounded by timeout. A missing CAP_NET_RAW for -M syn is treated
+// as fatal, since it's a process-wide condition no retry will fix.
+func doAttempt(spec hostSpec, addrs []string, i int, network string, dialer net.Dialer, method string, timeout time.Duration) probeSample {
+	if method == "syn" {
+		var srcIP net.IP
+		if dialer.LocalAddr != nil {
+			srcIP = dialer.LocalAddr.(*net.TCPAddr).IP
+		}
+		r, err := synProbe(spec.Host, spec.Port, timeout, srcIP)
+		if err != nil {
+			if errors.Is(err, ErrNeedCapNetRaw) {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			return probeSample{Error: "timeout"}
+		}
+		s := probeSample{Duration: r.Duration, HasRTT: true, Tag: r.Response}
+		if r.Response == "RST" {
+			s.Error = "reset"
+		}
+		return s
+	}
+
+	dialAddr := net.JoinHostPort(addrs[i%len(addrs)], strconv.Itoa(spec.Port))
+	return classifyProbe(dialer, network, dialAddr, timeout)
+}
+
+// sleepInterval pauses for interval, jittered by up to the given
+// fraction in either direction, so back-to-back attempts don't bias RTT
+// downward via cold caches/ARP or trip SYN-flood heuristics.
+func sleepInterval(interval time.Duration, jitter float64) {
+	if interval <= 0 {
+		return
+	}
+	d := interval
+	if jitter > 0 {
+		delta := (rand.Float64()*2 - 1) * jitter * float64(interval)
+		d = interval + time.Duration(delta)
+		if d < 0 {
+			d = 0
+		}
+	}
+	time.Sleep(d)
+}
+
+// runBatch probes every spec, bounding concurrency to parallel workers,
+// and prints one record per host in the same order specs was given and
+// in the requested -o format — fping -C style by default, but usable as
+// a JSON/Prometheus/InfluxDB exporter for any number of targets at once.
+func runBatch(specs []hostSpec, parallel int, opts probeOpts) int {
+	reports := make([]hostReport, len(specs))
+	good := make([]bool, len(specs))
+
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, spec := range specs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, spec hostSpec) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			reports[i], good[i] = probeHost(spec, opts)
+		}(i, spec)
+	}
+	wg.Wait()
+
+	format := formatFping
+	switch opts.Format {
+	case "json":
+		format = formatJSON
+	case "prom":
+		format = formatProm
+	case "influx":
+		format = formatInflux
+	}
+
+	exitCode := 0
+	for i, r := range reports {
+		fmt.Print(format(r))
+		if !good[i] {
+			exitCode = 1
+		}
+	}
+	return exitCode
+}
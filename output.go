@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// hostReport collects every probe sample gathered for one host, ready to
+// be rendered in any of the -o output formats.
+type hostReport struct {
+	Host    string
+	Port    int
+	Addr    string // representative resolved address, if one was picked
+	Samples []probeSample
+}
+
+// stats summarizes Samples into the familiar min/avg/max/mdev figures
+// plus a per-error-class breakdown. rttCount is how many samples carried
+// a Duration (success, refused, or reset).
+func (r hostReport) stats() (min, avg, max, mdev float64, rttCount int, errCounts map[string]int) {
+	errCounts = make(map[string]int)
+	var total, sumSquares float64
+	for _, s := range r.Samples {
+		if s.Error != "" {
+			errCounts[s.Error]++
+		}
+		if !s.HasRTT {
+			continue
+		}
+		ms := s.Duration.Seconds() * 1000.0
+		if rttCount == 0 || ms < min {
+			min = ms
+		}
+		if ms > max {
+			max = ms
+		}
+		total += ms
+		sumSquares += ms * ms
+		rttCount++
+	}
+	if rttCount > 0 {
+		avg = total / float64(rttCount)
+		variance := sumSquares/float64(rttCount) - avg*avg
+		if variance < 0 {
+			variance = 0
+		}
+		mdev = math.Sqrt(variance)
+	}
+	return min, avg, max, mdev, rttCount, errCounts
+}
+
+// formatFping renders r as the tool's native fping -C style line: the
+// host followed by one numeric sample per probe that produced an RTT
+// (timeouts/dns/unreachable failures are silently dropped, i.e. loss).
+func formatFping(r hostReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s :", r.Host)
+	for _, s := range r.Samples {
+		if !s.HasRTT {
+			continue
+		}
+		ms := s.Duration.Seconds() * 1000.0
+		if s.Tag != "" {
+			fmt.Fprintf(&b, " %.3f(%s)", ms, s.Tag)
+		} else {
+			fmt.Fprintf(&b, " %.3f", ms)
+		}
+	}
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// formatJSON renders r as a single JSON object with every field the
+// JSON -o format promises: host, addr, port, per-probe RTTs, loss count,
+// min/avg/max/mdev, and error class counts.
+func formatJSON(r hostReport) string {
+	min, avg, max, mdev, rttCount, errCounts := r.stats()
+
+	rtts := make([]float64, 0, len(r.Samples))
+	for _, s := range r.Samples {
+		if s.HasRTT {
+			rtts = append(rtts, s.Duration.Seconds()*1000.0)
+		}
+	}
+
+	out := struct {
+		Host   string         `json:"host"`
+		Addr   string         `json:"addr,omitempty"`
+		Port   int            `json:"port"`
+		RTTMs  []float64      `json:"rtt_ms"`
+		Loss   int            `json:"loss"`
+		MinMs  float64        `json:"min_ms"`
+		AvgMs  float64        `json:"avg_ms"`
+		MaxMs  float64        `json:"max_ms"`
+		MdevMs float64        `json:"mdev_ms"`
+		Errors map[string]int `json:"errors,omitempty"`
+	}{
+		Host:   r.Host,
+		Addr:   r.Addr,
+		Port:   r.Port,
+		RTTMs:  rtts,
+		Loss:   len(r.Samples) - rttCount,
+		MinMs:  min,
+		AvgMs:  avg,
+		MaxMs:  max,
+		MdevMs: mdev,
+		Errors: errCounts,
+	}
+
+	enc, err := json.Marshal(out)
+	if err != nil {
+		// Every field above is a plain value; Marshal cannot fail here.
+		panic(err)
+	}
+	return string(enc) + "\n"
+}
+
+// formatProm renders r as Prometheus text-exposition gauges, one metric
+// family per statistic, labeled by host and port — the same shape as
+// the Telegraf ping input's fields.
+func formatProm(r hostReport) string {
+	min, avg, max, mdev, rttCount, _ := r.stats()
+	loss := 0.0
+	if len(r.Samples) > 0 {
+		loss = 100 * float64(len(r.Samples)-rttCount) / float64(len(r.Samples))
+	}
+	labels := fmt.Sprintf(`host="%s",port="%d"`, r.Host, r.Port)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "tcpping_packets_transmitted{%s} %d\n", labels, len(r.Samples))
+	fmt.Fprintf(&b, "tcpping_packets_received{%s} %d\n", labels, rttCount)
+	fmt.Fprintf(&b, "tcpping_percent_packet_loss{%s} %s\n", labels, strconv.FormatFloat(loss, 'f', -1, 64))
+	if rttCount > 0 {
+		fmt.Fprintf(&b, "tcpping_min_response_ms{%s} %s\n", labels, strconv.FormatFloat(min, 'f', -1, 64))
+		fmt.Fprintf(&b, "tcpping_average_response_ms{%s} %s\n", labels, strconv.FormatFloat(avg, 'f', -1, 64))
+		fmt.Fprintf(&b, "tcpping_max_response_ms{%s} %s\n", labels, strconv.FormatFloat(max, 'f', -1, 64))
+		fmt.Fprintf(&b, "tcpping_stddev_response_ms{%s} %s\n", labels, strconv.FormatFloat(mdev, 'f', -1, 64))
+	}
+	return b.String()
+}
+
+// formatInflux renders r as a single InfluxDB line-protocol point,
+// mirroring the field names Telegraf's ping input emits.
+func formatInflux(r hostReport) string {
+	min, avg, max, mdev, rttCount, _ := r.stats()
+	loss := 0.0
+	if len(r.Samples) > 0 {
+		loss = 100 * float64(len(r.Samples)-rttCount) / float64(len(r.Samples))
+	}
+
+	fields := []string{
+		fmt.Sprintf("packets_transmitted=%di", len(r.Samples)),
+		fmt.Sprintf("packets_received=%di", rttCount),
+		fmt.Sprintf("percent_packet_loss=%s", strconv.FormatFloat(loss, 'f', -1, 64)),
+	}
+	if rttCount > 0 {
+		fields = append(fields,
+			fmt.Sprintf("minimum_response_ms=%s", strconv.FormatFloat(min, 'f', -1, 64)),
+			fmt.Sprintf("average_response_ms=%s", strconv.FormatFloat(avg, 'f', -1, 64)),
+			fmt.Sprintf("maximum_response_ms=%s", strconv.FormatFloat(max, 'f', -1, 64)),
+			fmt.Sprintf("stddev_response_ms=%s", strconv.FormatFloat(mdev, 'f', -1, 64)),
+		)
+	}
+
+	return fmt.Sprintf("tcpping,host=%s,port=%d %s\n", r.Host, r.Port, strings.Join(fields, ","))
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Target describes one host:port pair to probe on a fixed interval.
+// Count of 0 means "run until Stop is called" (daemon mode).
+type Target struct {
+	Host     string
+	Port     int
+	Interval time.Duration
+	Count    int
+	// Jitter randomizes each Interval by up to this fraction (0-1) in
+	// either direction, so a SYN-flood heuristic on the target can't key
+	// off a perfectly periodic probe.
+	Jitter float64
+
+	// Method is the probe method: "connect" (default, empty) or "syn"
+	// (raw half-open SYN, -M syn).
+	Method string
+	// Network is the net.Dialer network to use: "tcp", "tcp4", or "tcp6".
+	// Defaults to "tcp" when empty.
+	Network string
+	// Addrs are the resolved A/AAAA addresses to probe. When there is
+	// more than one, attempts round-robin across them. When empty,
+	// Host is resolved implicitly by net.Dialer on each dial.
+	Addrs []string
+	// LocalAddr, if set, binds the dialer's source address (-I).
+	LocalAddr net.Addr
+	// WarmupTimeout, if >0, sends one discarded probe with this timeout
+	// before the counted loop starts, to absorb cold cache/ARP latency
+	// (-W), mirroring the one-shot -C path's warm-up.
+	WarmupTimeout time.Duration
+}
+
+// nextInterval returns Interval, jittered by up to Jitter in either
+// direction.
+func (t Target) nextInterval() time.Duration {
+	if t.Jitter <= 0 {
+		return t.Interval
+	}
+	delta := (rand.Float64()*2 - 1) * t.Jitter * float64(t.Interval)
+	d := t.Interval + time.Duration(delta)
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+func (t Target) addr() string {
+	return net.JoinHostPort(t.Host, strconv.Itoa(t.Port))
+}
+
+func (t Target) network() string {
+	if t.Network == "" {
+		return "tcp"
+	}
+	return t.Network
+}
+
+// dialAddr returns the address the n'th attempt against t should dial,
+// round-robining across Addrs when more than one was resolved.
+func (t Target) dialAddr(n int) string {
+	if len(t.Addrs) == 0 {
+		return t.addr()
+	}
+	return net.JoinHostPort(t.Addrs[n%len(t.Addrs)], strconv.Itoa(t.Port))
+}
+
+// Result aggregates rolling min/avg/max/mdev and loss counters for one
+// target across however many probes have been attempted so far.
+type Result struct {
+	MinDuration    time.Duration
+	MaxDuration    time.Duration
+	TotalDuration  time.Duration
+	Counter        int    // attempts made
+	SuccessCounter int    // attempts that produced a latency sample
+	LastAddr       string // address dialed on the most recent attempt (host:port in syn mode or when Addrs is empty)
+
+	sumSquares float64 // sum of seconds^2, used to derive Mdev
+}
+
+func (r *Result) record(d time.Duration) {
+	r.Counter++
+	r.SuccessCounter++
+	r.TotalDuration += d
+	if r.SuccessCounter == 1 || d < r.MinDuration {
+		r.MinDuration = d
+	}
+	if d > r.MaxDuration {
+		r.MaxDuration = d
+	}
+	s := d.Seconds()
+	r.sumSquares += s * s
+}
+
+func (r *Result) recordLoss() {
+	r.Counter++
+}
+
+// Avg returns the mean round-trip time of successful probes.
+func (r *Result) Avg() time.Duration {
+	if r.SuccessCounter == 0 {
+		return 0
+	}
+	return r.TotalDuration / time.Duration(r.SuccessCounter)
+}
+
+// Mdev returns the standard deviation of successful probes' RTTs, the
+// figure the mdev column of ping(8) prints alongside min/avg/max.
+func (r *Result) Mdev() time.Duration {
+	if r.SuccessCounter == 0 {
+		return 0
+	}
+	mean := r.Avg().Seconds()
+	variance := r.sumSquares/float64(r.SuccessCounter) - mean*mean
+	if variance < 0 {
+		variance = 0
+	}
+	return time.Duration(math.Sqrt(variance) * float64(time.Second))
+}
+
+// Loss returns the percentage of attempts that did not yield a sample.
+func (r *Result) Loss() float64 {
+	if r.Counter == 0 {
+		return 0
+	}
+	return 100 * float64(r.Counter-r.SuccessCounter) / float64(r.Counter)
+}
+
+// probeAttempt dials addr over network ("tcp", "tcp4", or "tcp6") once,
+// bounded by timeout, and reports whether it produced a usable latency
+// sample, applying the same “refused/reset counts as reachable” rule as
+// the one-shot -C path.
+func probeAttempt(dialer net.Dialer, network, addr string, timeout time.Duration) (time.Duration, bool) {
+	s := classifyProbe(dialer, network, addr, timeout)
+	return s.Duration, s.HasRTT
+}
+
+// synAttempt sends one half-open SYN probe via synProbe and reports
+// whether it produced a usable latency sample, treating a RST the same
+// as a SYN-ACK (reachable), same as the one-shot -C path's doAttempt. A
+// missing CAP_NET_RAW is fatal, since it's a process-wide condition no
+// retry will fix.
+func synAttempt(host string, port int, timeout time.Duration, srcIP net.IP) (time.Duration, bool) {
+	r, err := synProbe(host, port, timeout, srcIP)
+	if err != nil {
+		if errors.Is(err, ErrNeedCapNetRaw) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return 0, false
+	}
+	return r.Duration, true
+}
+
+// Prober runs one or more Targets on independent, optionally jittered
+// timers, turning tcpping into a long-running monitor that prints
+// rolling per-target summaries instead of a single fping -C line.
+type Prober struct {
+	targets []Target
+	timeout time.Duration
+	format  string // "fping", "json", "prom", or "influx"
+
+	mu      sync.Mutex
+	results map[string]*Result
+
+	sem chan struct{} // bounds concurrent in-flight dials across all targets (-p)
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewProber builds a Prober for targets, dialing with the given per-attempt
+// timeout and rendering rolling summaries in format, with no more than
+// parallel dials in flight across all targets at once (-p).
+func NewProber(targets []Target, timeout time.Duration, format string, parallel int) *Prober {
+	results := make(map[string]*Result, len(targets))
+	for _, t := range targets {
+		results[t.addr()] = &Result{}
+	}
+	if parallel < 1 {
+		parallel = 1
+	}
+	return &Prober{
+		targets: targets,
+		timeout: timeout,
+		format:  format,
+		results: results,
+		sem:     make(chan struct{}, parallel),
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Start launches one goroutine per target and returns a channel that is
+// closed once every target has stopped, whether because its Count was
+// reached or because Stop was called.
+func (p *Prober) Start() <-chan struct{} {
+	done := make(chan struct{})
+	for _, t := range p.targets {
+		p.wg.Add(1)
+		go p.run(t)
+	}
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// Stop cancels all running probe loops. Safe to call more than once.
+func (p *Prober) Stop() {
+	select {
+	case <-p.stopCh:
+	default:
+		close(p.stopCh)
+	}
+}
+
+func (p *Prober) run(t Target) {
+	defer p.wg.Done()
+	dialer := net.Dialer{LocalAddr: t.LocalAddr}
+	key := t.addr()
+	network := t.network()
+
+	var srcIP net.IP
+	if tcpAddr, ok := t.LocalAddr.(*net.TCPAddr); ok && tcpAddr != nil {
+		srcIP = tcpAddr.IP
+	}
+
+	// probeOnce runs one bounded attempt (connect or, for -M syn, a raw
+	// half-open SYN) and returns the address probed alongside the result.
+	probeOnce := func(n int, timeout time.Duration) (string, time.Duration, bool) {
+		p.sem <- struct{}{}
+		defer func() { <-p.sem }()
+		if t.Method == "syn" {
+			dur, ok := synAttempt(t.Host, t.Port, timeout, srcIP)
+			return t.addr(), dur, ok
+		}
+		dialAddr := t.dialAddr(n)
+		dur, ok := probeAttempt(dialer, network, dialAddr, timeout)
+		return dialAddr, dur, ok
+	}
+
+	attempt := func(n int) {
+		dialAddr, dur, ok := probeOnce(n, p.timeout)
+
+		p.mu.Lock()
+		res := p.results[key]
+		res.LastAddr = dialAddr
+		if ok {
+			res.record(dur)
+		} else {
+			res.recordLoss()
+		}
+		p.report(t, res)
+		p.mu.Unlock()
+	}
+
+	if t.WarmupTimeout > 0 {
+		probeOnce(0, t.WarmupTimeout)
+	}
+
+	attempt(0)
+	timer := time.NewTimer(t.nextInterval())
+	defer timer.Stop()
+	for n := 1; t.Count == 0 || n < t.Count; n++ {
+		select {
+		case <-p.stopCh:
+			return
+		case <-timer.C:
+			attempt(n)
+			timer.Reset(t.nextInterval())
+		}
+	}
+}
+
+// report prints a rolling summary for t in the configured -o format.
+// Callers must hold p.mu.
+func (p *Prober) report(t Target, res *Result) {
+	switch p.format {
+	case "json":
+		fmt.Fprint(os.Stdout, reportJSON(t, res))
+	case "prom":
+		fmt.Fprint(os.Stdout, reportProm(t, res))
+	case "influx":
+		fmt.Fprint(os.Stdout, reportInflux(t, res))
+	default:
+		label := t.addr()
+		if len(t.Addrs) > 1 {
+			label = fmt.Sprintf("%s (%s)", label, res.LastAddr)
+		}
+		fmt.Fprintf(os.Stdout, "%s : xmt/rcv/%%loss = %d/%d/%.0f%%, min/avg/max/mdev = %.3f/%.3f/%.3f/%.3f ms\n",
+			label, res.Counter, res.SuccessCounter, res.Loss(),
+			msOf(res.MinDuration), msOf(res.Avg()), msOf(res.MaxDuration), msOf(res.Mdev()))
+	}
+}
+
+func msOf(d time.Duration) float64 {
+	return d.Seconds() * 1000.0
+}
+
+// reportJSON renders t's rolling Result as a single JSON object — the
+// daemon-mode equivalent of the batch path's formatJSON, emitted once
+// per attempt instead of once per run.
+func reportJSON(t Target, res *Result) string {
+	out := struct {
+		Host   string  `json:"host"`
+		Port   int     `json:"port"`
+		Xmt    int     `json:"packets_transmitted"`
+		Rcv    int     `json:"packets_received"`
+		LossPc float64 `json:"percent_packet_loss"`
+		MinMs  float64 `json:"min_ms"`
+		AvgMs  float64 `json:"avg_ms"`
+		MaxMs  float64 `json:"max_ms"`
+		MdevMs float64 `json:"mdev_ms"`
+	}{
+		Host:   t.Host,
+		Port:   t.Port,
+		Xmt:    res.Counter,
+		Rcv:    res.SuccessCounter,
+		LossPc: res.Loss(),
+		MinMs:  msOf(res.MinDuration),
+		AvgMs:  msOf(res.Avg()),
+		MaxMs:  msOf(res.MaxDuration),
+		MdevMs: msOf(res.Mdev()),
+	}
+	enc, err := json.Marshal(out)
+	if err != nil {
+		// Every field above is a plain value; Marshal cannot fail here.
+		panic(err)
+	}
+	return string(enc) + "\n"
+}
+
+// reportProm renders t's rolling Result as Prometheus text-exposition
+// gauges, the daemon-mode equivalent of formatProm.
+func reportProm(t Target, res *Result) string {
+	labels := fmt.Sprintf(`host="%s",port="%d"`, t.Host, t.Port)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "tcpping_packets_transmitted{%s} %d\n", labels, res.Counter)
+	fmt.Fprintf(&b, "tcpping_packets_received{%s} %d\n", labels, res.SuccessCounter)
+	fmt.Fprintf(&b, "tcpping_percent_packet_loss{%s} %s\n", labels, strconv.FormatFloat(res.Loss(), 'f', -1, 64))
+	if res.SuccessCounter > 0 {
+		fmt.Fprintf(&b, "tcpping_min_response_ms{%s} %s\n", labels, strconv.FormatFloat(msOf(res.MinDuration), 'f', -1, 64))
+		fmt.Fprintf(&b, "tcpping_average_response_ms{%s} %s\n", labels, strconv.FormatFloat(msOf(res.Avg()), 'f', -1, 64))
+		fmt.Fprintf(&b, "tcpping_max_response_ms{%s} %s\n", labels, strconv.FormatFloat(msOf(res.MaxDuration), 'f', -1, 64))
+		fmt.Fprintf(&b, "tcpping_stddev_response_ms{%s} %s\n", labels, strconv.FormatFloat(msOf(res.Mdev()), 'f', -1, 64))
+	}
+	return b.String()
+}
+
+// reportInflux renders t's rolling Result as a single InfluxDB
+// line-protocol point, the daemon-mode equivalent of formatInflux.
+func reportInflux(t Target, res *Result) string {
+	fields := []string{
+		fmt.Sprintf("packets_transmitted=%di", res.Counter),
+		fmt.Sprintf("packets_received=%di", res.SuccessCounter),
+		fmt.Sprintf("percent_packet_loss=%s", strconv.FormatFloat(res.Loss(), 'f', -1, 64)),
+	}
+	if res.SuccessCounter > 0 {
+		fields = append(fields,
+			fmt.Sprintf("minimum_response_ms=%s", strconv.FormatFloat(msOf(res.MinDuration), 'f', -1, 64)),
+			fmt.Sprintf("average_response_ms=%s", strconv.FormatFloat(msOf(res.Avg()), 'f', -1, 64)),
+			fmt.Sprintf("maximum_response_ms=%s", strconv.FormatFloat(msOf(res.MaxDuration), 'f', -1, 64)),
+			fmt.Sprintf("stddev_response_ms=%s", strconv.FormatFloat(msOf(res.Mdev()), 'f', -1, 64)),
+		)
+	}
+	return fmt.Sprintf("tcpping,host=%s,port=%d %s\n", t.Host, t.Port, strings.Join(fields, ","))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// dialNetwork maps an address-family selection (0 = either, 4, 6) to the
+// network string net.Dialer expects.
+func dialNetwork(family int) string {
+	switch family {
+	case 4:
+		return "tcp4"
+	case 6:
+		return "tcp6"
+	default:
+		return "tcp"
+	}
+}
+
+// resolveAddrs looks up host's A/AAAA records, filters them to the
+// requested family (0 means both), and returns them as plain IP strings
+// so callers can round-robin across them and report which one was used.
+func resolveAddrs(host string, family int) ([]string, error) {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", host, err)
+	}
+
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		isV4 := ip.To4() != nil
+		switch family {
+		case 4:
+			if !isV4 {
+				continue
+			}
+		case 6:
+			if isV4 {
+				continue
+			}
+		}
+		addrs = append(addrs, ip.String())
+	}
+
+	if len(addrs) == 0 {
+		if family == 4 {
+			return nil, fmt.Errorf("%s has no IPv4 address", host)
+		}
+		if family == 6 {
+			return nil, fmt.Errorf("%s has no IPv6 address", host)
+		}
+		return nil, fmt.Errorf("%s did not resolve to any address", host)
+	}
+	return addrs, nil
+}
+
+// resolveSourceAddr turns a -I argument — either a literal source
+// address or an interface name — into the IP to bind the dialer to.
+func resolveSourceAddr(spec string, family int) (net.IP, error) {
+	if ip := net.ParseIP(spec); ip != nil {
+		return ip, nil
+	}
+
+	iface, err := net.InterfaceByName(spec)
+	if err != nil {
+		return nil, fmt.Errorf("-I %q is neither a source address nor an interface: %w", spec, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("list addresses on %s: %w", spec, err)
+	}
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		isV4 := ipNet.IP.To4() != nil
+		if family == 4 && !isV4 {
+			continue
+		}
+		if family == 6 && isV4 {
+			continue
+		}
+		return ipNet.IP, nil
+	}
+	return nil, fmt.Errorf("interface %s has no suitable address", spec)
+}
@@ -0,0 +1,158 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+)
+
+// ErrNeedCapNetRaw is returned when opening the raw socket fails because
+// the process lacks the privilege to do so.
+var ErrNeedCapNetRaw = errors.New("SYN mode requires CAP_NET_RAW (run as root or `setcap cap_net_raw+ep` on this binary)")
+
+// SynResult is the outcome of one half-open SYN probe.
+type SynResult struct {
+	Duration time.Duration
+	Response string // "SYN-ACK" (port open) or "RST" (port closed)
+}
+
+// synProbe sends a single raw TCP SYN to host:port, measures the time
+// until a matching SYN-ACK or RST arrives, and — for a SYN-ACK — sends a
+// RST of its own so the handshake is never completed. IPv6 is not yet
+// supported here. When srcIP is non-nil (-I), the raw socket is bound to
+// it so the SYN both egresses from and is built honestly with that
+// source address, instead of whatever the kernel's routing table would
+// otherwise pick.
+func synProbe(host string, port int, timeout time.Duration, srcIP net.IP) (SynResult, error) {
+	dstIP, err := resolveIPv4(host)
+	if err != nil {
+		return SynResult{}, err
+	}
+
+	var srcArr [4]byte
+	if srcIP != nil {
+		v4 := srcIP.To4()
+		if v4 == nil {
+			return SynResult{}, fmt.Errorf("-I source %s is not an IPv4 address (SYN mode is IPv4-only for now)", srcIP)
+		}
+		copy(srcArr[:], v4)
+	} else {
+		srcArr, err = outboundIPv4(dstIP)
+		if err != nil {
+			return SynResult{}, err
+		}
+	}
+
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_RAW, syscall.IPPROTO_TCP)
+	if err != nil {
+		if errors.Is(err, syscall.EPERM) {
+			return SynResult{}, ErrNeedCapNetRaw
+		}
+		return SynResult{}, fmt.Errorf("open raw socket: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	if srcIP != nil {
+		if err := syscall.Bind(fd, &syscall.SockaddrInet4{Addr: srcArr}); err != nil {
+			return SynResult{}, fmt.Errorf("bind raw socket to %s: %w", srcIP, err)
+		}
+	}
+
+	tv := syscall.NsecToTimeval(timeout.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		return SynResult{}, fmt.Errorf("set recv timeout: %w", err)
+	}
+
+	srcPort := uint16(1024 + rand.Intn(64511))
+	seq := rand.Uint32()
+	dst := syscall.SockaddrInet4{Port: port, Addr: dstIP}
+
+	start := time.Now()
+	syn := buildTCPSYN(srcArr, dstIP, srcPort, uint16(port), seq)
+	if err := syscall.Sendto(fd, syn, 0, &dst); err != nil {
+		return SynResult{}, fmt.Errorf("send SYN: %w", err)
+	}
+
+	buf := make([]byte, 4096)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if errors.Is(err, syscall.EAGAIN) {
+				return SynResult{}, fmt.Errorf("timeout waiting for response")
+			}
+			return SynResult{}, fmt.Errorf("recv: %w", err)
+		}
+		dur := time.Since(start)
+
+		tcp, ok := parseIPv4TCP(buf[:n])
+		if !ok || tcp.SrcPort != uint16(port) || tcp.DstPort != srcPort || tcp.AckNum != seq+1 {
+			// Not our reply: keep waiting, but only for what is left of timeout.
+			remaining := timeout - dur
+			if remaining < time.Microsecond {
+				return SynResult{}, fmt.Errorf("timeout waiting for response")
+			}
+			tv := syscall.NsecToTimeval(remaining.Nanoseconds())
+			if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+				return SynResult{}, fmt.Errorf("set recv timeout: %w", err)
+			}
+			continue
+		}
+
+		switch {
+		case tcp.Flags&tcpFlagRST != 0:
+			return SynResult{Duration: dur, Response: "RST"}, nil
+		case tcp.Flags&tcpFlagSYN != 0 && tcp.Flags&tcpFlagACK != 0:
+			sendRST(fd, srcArr, dstIP, srcPort, uint16(port), seq+1, dst)
+			return SynResult{Duration: dur, Response: "SYN-ACK"}, nil
+		}
+	}
+}
+
+// sendRST tears down the half-open connection left by a received SYN-ACK
+// so the probe never completes a full handshake.
+func sendRST(fd int, srcIP, dstIP [4]byte, srcPort, dstPort uint16, seq uint32, dst syscall.SockaddrInet4) {
+	hdr := buildTCPSYN(srcIP, dstIP, srcPort, dstPort, seq)
+	hdr[13] = tcpFlagRST
+	binary.BigEndian.PutUint16(hdr[16:18], 0)
+	binary.BigEndian.PutUint16(hdr[16:18], tcpChecksum(srcIP, dstIP, hdr))
+	_ = syscall.Sendto(fd, hdr, 0, &dst)
+}
+
+func resolveIPv4(host string) ([4]byte, error) {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return [4]byte{}, fmt.Errorf("resolve %s: %w", host, err)
+	}
+	for _, ip := range ips {
+		if v4 := ip.To4(); v4 != nil {
+			var out [4]byte
+			copy(out[:], v4)
+			return out, nil
+		}
+	}
+	return [4]byte{}, fmt.Errorf("%s has no IPv4 address (SYN mode is IPv4-only for now)", host)
+}
+
+// outboundIPv4 finds the local address the kernel would use to reach
+// dst, without sending any traffic (UDP "connect" just resolves a route).
+func outboundIPv4(dst [4]byte) ([4]byte, error) {
+	conn, err := net.Dial("udp4", net.JoinHostPort(net.IP(dst[:]).String(), "1"))
+	if err != nil {
+		return [4]byte{}, fmt.Errorf("determine source address for %v: %w", net.IP(dst[:]), err)
+	}
+	defer conn.Close()
+
+	v4 := conn.LocalAddr().(*net.UDPAddr).IP.To4()
+	if v4 == nil {
+		return [4]byte{}, fmt.Errorf("local address is not IPv4")
+	}
+	var out [4]byte
+	copy(out[:], v4)
+	return out, nil
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"time"
+)
+
+// ErrNeedCapNetRaw mirrors the Linux build's error so callers can check
+// for it uniformly; it is never actually returned here.
+var ErrNeedCapNetRaw = fmt.Errorf("SYN mode requires CAP_NET_RAW")
+
+// SynResult is the outcome of one half-open SYN probe.
+type SynResult struct {
+	Duration time.Duration
+	Response string
+}
+
+// synProbe is not implemented outside Linux; -M syn fails fast with a
+// clear error instead of silently falling back to connect mode.
+func synProbe(host string, port int, timeout time.Duration, srcIP net.IP) (SynResult, error) {
+	return SynResult{}, fmt.Errorf("SYN mode is not supported on %s yet", runtime.GOOS)
+}
@@ -0,0 +1,84 @@
+package main
+
+import "encoding/binary"
+
+// TCP flag bits used when crafting and parsing the raw SYN probe.
+const (
+	tcpFlagFIN = 1 << 0
+	tcpFlagSYN = 1 << 1
+	tcpFlagRST = 1 << 2
+	tcpFlagACK = 1 << 4
+)
+
+// buildTCPSYN serializes a minimal 20-byte TCP header with only the SYN
+// flag set and a correct checksum over the IPv4 pseudo-header, ready to
+// be handed to a SOCK_RAW/IPPROTO_TCP socket (the kernel fills in the IP
+// header itself since IP_HDRINCL is not set).
+func buildTCPSYN(srcIP, dstIP [4]byte, srcPort, dstPort uint16, seq uint32) []byte {
+	hdr := make([]byte, 20)
+	binary.BigEndian.PutUint16(hdr[0:2], srcPort)
+	binary.BigEndian.PutUint16(hdr[2:4], dstPort)
+	binary.BigEndian.PutUint32(hdr[4:8], seq)
+	binary.BigEndian.PutUint32(hdr[8:12], 0) // ack num, unused on a SYN
+	hdr[12] = 5 << 4                         // data offset: 5 words, no options
+	hdr[13] = tcpFlagSYN
+	binary.BigEndian.PutUint16(hdr[14:16], 65535) // window
+	binary.BigEndian.PutUint16(hdr[16:18], 0)     // checksum, filled below
+	binary.BigEndian.PutUint16(hdr[18:20], 0)     // urgent pointer
+
+	binary.BigEndian.PutUint16(hdr[16:18], tcpChecksum(srcIP, dstIP, hdr))
+	return hdr
+}
+
+// tcpChecksum computes the standard one's-complement TCP checksum over
+// the IPv4 pseudo-header + segment.
+func tcpChecksum(srcIP, dstIP [4]byte, segment []byte) uint16 {
+	pseudo := make([]byte, 12+len(segment))
+	copy(pseudo[0:4], srcIP[:])
+	copy(pseudo[4:8], dstIP[:])
+	pseudo[8] = 0
+	pseudo[9] = 6 // protocol: TCP
+	binary.BigEndian.PutUint16(pseudo[10:12], uint16(len(segment)))
+	copy(pseudo[12:], segment)
+
+	var sum uint32
+	for i := 0; i+1 < len(pseudo); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(pseudo[i : i+2]))
+	}
+	if len(pseudo)%2 == 1 {
+		sum += uint32(pseudo[len(pseudo)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = (sum & 0xffff) + (sum >> 16)
+	}
+	return ^uint16(sum)
+}
+
+// parsedTCP holds the fields of an incoming TCP segment that the SYN
+// prober cares about.
+type parsedTCP struct {
+	SrcPort uint16
+	DstPort uint16
+	AckNum  uint32
+	Flags   byte
+}
+
+// parseIPv4TCP strips the (variable-length) IPv4 header from a raw packet
+// read off a SOCK_RAW/IPPROTO_TCP socket and parses the TCP header that
+// follows. It reports ok=false if buf is too short to contain both.
+func parseIPv4TCP(buf []byte) (parsedTCP, bool) {
+	if len(buf) < 20 {
+		return parsedTCP{}, false
+	}
+	ihl := int(buf[0]&0x0f) * 4
+	if ihl < 20 || len(buf) < ihl+20 {
+		return parsedTCP{}, false
+	}
+	tcp := buf[ihl:]
+	return parsedTCP{
+		SrcPort: binary.BigEndian.Uint16(tcp[0:2]),
+		DstPort: binary.BigEndian.Uint16(tcp[2:4]),
+		AckNum:  binary.BigEndian.Uint32(tcp[8:12]),
+		Flags:   tcp[13],
+	}, true
+}
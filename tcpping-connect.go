@@ -1,11 +1,11 @@
 package main
 
 import (
-	"errors"
 	"flag"
 	"fmt"
 	"net"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
 	"syscall"
@@ -24,10 +24,9 @@ func getenvFloat(name string, def float64) float64 {
 	return f
 }
 
-func isRefusedOrReset(err error) bool {
-	// Treat “connection refused” / “connection reset” as *reachable* (like TCP SYN RTT)
-	// so that closed ports still yield a latency value (RST is a response).
-	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+func isPlainInt(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
 }
 
 func usage() {
@@ -35,14 +34,25 @@ func usage() {
 	if len(os.Args) > 0 {
 		name = os.Args[0]
 	}
-	fmt.Fprintf(os.Stderr, "Usage: %s -C -x <count> [-w <timeout_sec>] <host> [port]\n\n", name)
+	fmt.Fprintf(os.Stderr, "Usage: %s -C -x <count> [-w <timeout_sec>] <host[:port]>...\n\n", name)
 	fmt.Fprintln(os.Stderr, "SmokePing calls: <binary> -C -x N <host> [port]")
-	fmt.Fprintln(os.Stderr, "Outputs: <host> : <ms> <ms> ... (successful probes only)")
+	fmt.Fprintln(os.Stderr, "Outputs: <host> : <ms> <ms> ... (one line per host, successful probes only; a sample may carry an (addr) or (SYN-ACK/RST) tag)")
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Options:")
 	fmt.Fprintln(os.Stderr, "  -C              fping -C compatible output (always recommended)")
 	fmt.Fprintln(os.Stderr, "  -x <count>      number of connection attempts")
 	fmt.Fprintln(os.Stderr, "  -w <seconds>    per-attempt timeout (float supported); default 1.0")
+	fmt.Fprintln(os.Stderr, "  -D, --daemon    keep probing at -i seconds and print rolling summaries")
+	fmt.Fprintln(os.Stderr, "  -i <seconds>    interval between attempts; default 1.0")
+	fmt.Fprintln(os.Stderr, "  -W <seconds>    warm-up: send one discarded probe with this timeout before the real run")
+	fmt.Fprintln(os.Stderr, "  --jitter <frac> randomize -i by up to this fraction (0-1) in either direction")
+	fmt.Fprintln(os.Stderr, "  -M <mode>       probe method: connect (default) or syn (raw half-open SYN)")
+	fmt.Fprintln(os.Stderr, "  -4              resolve and dial IPv4 only")
+	fmt.Fprintln(os.Stderr, "  -6              resolve and dial IPv6 only")
+	fmt.Fprintln(os.Stderr, "  -I <iface|addr> bind the dialer's local address to this interface or source address")
+	fmt.Fprintln(os.Stderr, "  -f <file>       read host[:port] targets from file, one per line, instead of argv")
+	fmt.Fprintln(os.Stderr, "  -p <n>          max number of hosts to probe concurrently; default 1")
+	fmt.Fprintln(os.Stderr, "  -o <format>     output format: fping (default), json, prom, or influx")
 	fmt.Fprintln(os.Stderr, "")
 	fmt.Fprintln(os.Stderr, "Environment:")
 	fmt.Fprintln(os.Stderr, "  TCPPING_TIMEOUT or TCPPING_TIMEOUT_SEC  default timeout seconds")
@@ -55,27 +65,61 @@ func main() {
 	C := flag.Bool("C", false, "fping -C compatible output")
 	x := flag.Int("x", 1, "repeat count")
 	w := flag.Float64("w", defaultTimeout, "per-try timeout seconds")
+	interval := flag.Float64("i", 1.0, "interval between attempts in seconds")
+	warmup := flag.Float64("W", 0, "warm-up probe timeout seconds; 0 disables")
+	jitter := flag.Float64("jitter", 0, "randomize -i by up to this fraction (0-1)")
+	method := flag.String("M", "connect", "probe method: connect or syn")
+	four := flag.Bool("4", false, "resolve and dial IPv4 only")
+	six := flag.Bool("6", false, "resolve and dial IPv6 only")
+	source := flag.String("I", "", "bind to this interface name or source address")
+	file := flag.String("f", "", "read host[:port] targets from this file instead of argv")
+	parallel := flag.Int("p", 1, "max number of hosts to probe concurrently")
+	format := flag.String("o", "fping", "output format: fping, json, prom, or influx")
+
+	var daemon bool
+	flag.BoolVar(&daemon, "D", false, "daemon mode: keep probing at -i seconds and print rolling summaries")
+	flag.BoolVar(&daemon, "daemon", false, "long form of -D")
 
 	flag.Usage = usage
 	flag.Parse()
 
 	args := flag.Args()
-	if len(args) < 1 {
-		usage()
-		os.Exit(2)
-	}
 
-	host := args[0]
-	port := 80
-	if len(args) >= 2 {
-		p, err := strconv.Atoi(args[1])
-		if err != nil || p < 0 || p > 65535 {
+	var specs []hostSpec
+	switch {
+	case *file != "":
+		var err error
+		specs, err = loadHostFile(*file, 80)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	case len(args) == 2 && isPlainInt(args[1]):
+		// Legacy SmokePing invocation: <binary> -C -x N <host> <port>
+		port, _ := strconv.Atoi(args[1])
+		if port < 0 || port > 65535 {
 			fmt.Fprintf(os.Stderr, "Invalid port: %q\n", args[1])
 			os.Exit(2)
 		}
-		port = p
+		specs = []hostSpec{{Host: args[0], Port: port}}
+	case len(args) >= 1:
+		for _, a := range args {
+			spec, err := parseHostSpec(a, 80)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+			specs = append(specs, spec)
+		}
+	default:
+		usage()
+		os.Exit(2)
 	}
 
+	if *parallel < 1 {
+		fmt.Fprintln(os.Stderr, "-p must be >= 1")
+		os.Exit(2)
+	}
 	if *x < 1 {
 		fmt.Fprintln(os.Stderr, "-x must be >= 1")
 		os.Exit(2)
@@ -84,48 +128,120 @@ func main() {
 		fmt.Fprintln(os.Stderr, "-w must be > 0")
 		os.Exit(2)
 	}
+	if *interval <= 0 {
+		fmt.Fprintln(os.Stderr, "-i must be > 0")
+		os.Exit(2)
+	}
+	if *warmup < 0 {
+		fmt.Fprintln(os.Stderr, "-W must be >= 0")
+		os.Exit(2)
+	}
+	if *jitter < 0 || *jitter >= 1 {
+		fmt.Fprintln(os.Stderr, "--jitter must be in [0, 1)")
+		os.Exit(2)
+	}
+	if *method != "connect" && *method != "syn" {
+		fmt.Fprintf(os.Stderr, "Invalid -M mode: %q (want connect or syn)\n", *method)
+		os.Exit(2)
+	}
+	if *four && *six {
+		fmt.Fprintln(os.Stderr, "-4 and -6 are mutually exclusive")
+		os.Exit(2)
+	}
+	family := 0
+	switch {
+	case *four:
+		family = 4
+	case *six:
+		family = 6
+	}
+	if *method == "syn" && family == 6 {
+		fmt.Fprintln(os.Stderr, "-M syn does not support IPv6 yet")
+		os.Exit(2)
+	}
+	switch *format {
+	case "fping", "json", "prom", "influx":
+	default:
+		fmt.Fprintf(os.Stderr, "Invalid -o format: %q (want fping, json, prom, or influx)\n", *format)
+		os.Exit(2)
+	}
 
-	addr := net.JoinHostPort(host, strconv.Itoa(port))
-	dialer := net.Dialer{Timeout: time.Duration(*w * float64(time.Second))}
-
-	ms := make([]float64, 0, *x)
-	for i := 0; i < *x; i++ {
-		start := time.Now()
-		conn, err := dialer.Dial("tcp", addr)
-		dur := time.Since(start)
-		if conn != nil {
-			_ = conn.Close()
-		}
-
+	var localAddr net.Addr
+	if *source != "" {
+		srcIP, err := resolveSourceAddr(*source, family)
 		if err != nil {
-			// Timeout / no route / DNS fail: count as loss
-			if ne, ok := err.(net.Error); ok && ne.Timeout() {
-				continue
-			}
-			// Connection refused/reset: reachable (RST), keep a latency sample
-			if isRefusedOrReset(err) {
-				ms = append(ms, dur.Seconds()*1000.0)
-				continue
-			}
-			continue
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		localAddr = &net.TCPAddr{IP: srcIP}
+		if *method == "syn" && srcIP.To4() == nil {
+			fmt.Fprintln(os.Stderr, "-I must resolve to an IPv4 address for -M syn (SYN mode is IPv4-only for now)")
+			os.Exit(2)
 		}
-
-		ms = append(ms, dur.Seconds()*1000.0)
 	}
 
-	// SmokePing’s TCPPing probe expects -C style output.
+	// SmokePing’s TCPPing probe expects -C style output; we only ever
+	// produce that format, so -C is accepted but doesn't change behavior.
 	_ = *C
 
-	if len(ms) == 0 {
-		// Print a line (useful for manual runs). SmokePing will treat “no numeric samples” as full loss.
-		fmt.Printf("%s :\n", host)
-		os.Exit(1)
+	if daemon {
+		runDaemon(specs, *interval, *jitter, *w, *warmup, *format, *method, *parallel, dialNetwork(family), family, localAddr)
+		return
 	}
 
-	fmt.Printf("%s :", host)
-	for _, v := range ms {
-		fmt.Printf(" %.3f", v)
+	opts := probeOpts{
+		Count:         *x,
+		Timeout:       time.Duration(*w * float64(time.Second)),
+		Method:        *method,
+		Family:        family,
+		LocalAddr:     localAddr,
+		Format:        *format,
+		Interval:      time.Duration(*interval * float64(time.Second)),
+		Jitter:        *jitter,
+		WarmupTimeout: time.Duration(*warmup * float64(time.Second)),
+	}
+	os.Exit(runBatch(specs, *parallel, opts))
+}
+
+// runDaemon keeps probing every spec at the given interval and prints a
+// rolling min/avg/max/mdev/loss summary (or, per format, a JSON/Prometheus/
+// InfluxDB record) after every attempt, until interrupted. No more than
+// parallel dials run concurrently across all specs. On SIGINT or SIGTERM
+// it stops every probe loop and returns once they have all finished.
+func runDaemon(specs []hostSpec, intervalSec, jitter, timeoutSec, warmupSec float64, format, method string, parallel int, network string, family int, localAddr net.Addr) {
+	targets := make([]Target, 0, len(specs))
+	for _, spec := range specs {
+		// A resolve failure here is just the first attempt; it must not
+		// take down monitoring of every other target. Leave Addrs nil so
+		// the dialer (or synProbe) resolves the host itself on each
+		// attempt instead, which naturally retries and recovers once DNS
+		// is reachable again.
+		addrs, err := resolveAddrs(spec.Host, family)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		targets = append(targets, Target{
+			Host:          spec.Host,
+			Port:          spec.Port,
+			Interval:      time.Duration(intervalSec * float64(time.Second)),
+			Jitter:        jitter,
+			Method:        method,
+			Network:       network,
+			Addrs:         addrs,
+			LocalAddr:     localAddr,
+			WarmupTimeout: time.Duration(warmupSec * float64(time.Second)),
+		})
+	}
+	prober := NewProber(targets, time.Duration(timeoutSec*float64(time.Second)), format, parallel)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+	done := prober.Start()
+	select {
+	case <-sig:
+		prober.Stop()
+		<-done
+	case <-done:
 	}
-	fmt.Printf("\n")
-	os.Exit(0)
 }